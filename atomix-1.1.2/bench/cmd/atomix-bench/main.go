@@ -7,6 +7,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/atomix/atomix/api/errors"
 	"github.com/atomix/atomix/runtime/pkg/logging"
 	"github.com/atomix/atomix/runtime/pkg/utils/async"
@@ -17,11 +18,145 @@ import (
 	"math/rand"
 	"os"
 	"os/signal"
+	"runtime"
 	"sync/atomic"
 	"syscall"
 	"time"
 )
 
+const (
+	// histogramMinValue is the lowest latency, in nanoseconds, trackable by the benchmark histograms.
+	histogramMinValue = int64(time.Microsecond)
+	// histogramMaxValue is the highest latency, in nanoseconds, trackable by the benchmark histograms.
+	histogramMaxValue = int64(60 * time.Second)
+	// histogramSigFigs is the number of significant figures preserved by the benchmark histograms.
+	histogramSigFigs = 3
+)
+
+// swappableHistogram is a pair of HDR histograms that can be flipped so a worker goroutine
+// keeps recording into one half while the sampling goroutine drains the other. record() is
+// lock-free: it never blocks and only uses atomic increments. swap() runs on the single
+// ticker goroutine and briefly waits out any record() calls already in flight against the
+// half it's about to hand back for merging and resetting, so that half is never touched by
+// RecordValue and Reset/Merge at the same time.
+type swappableHistogram struct {
+	buf      [2]*hdrhistogram.Histogram
+	active   atomic.Int32
+	inflight [2]atomic.Int32
+}
+
+func newSwappableHistogram() *swappableHistogram {
+	return &swappableHistogram{
+		buf: [2]*hdrhistogram.Histogram{
+			hdrhistogram.New(histogramMinValue, histogramMaxValue, histogramSigFigs),
+			hdrhistogram.New(histogramMinValue, histogramMaxValue, histogramSigFigs),
+		},
+	}
+}
+
+// record adds a latency sample to the currently active histogram. It registers interest in
+// an index before recording into it, then confirms swap() didn't flip the active index in
+// the meantime; if it did, it backs out and retries against the new index. This closes the
+// window between reading the active index and recording into it.
+func (h *swappableHistogram) record(value int64) {
+	var idx int32
+	for {
+		idx = h.active.Load()
+		h.inflight[idx].Add(1)
+		if h.active.Load() == idx {
+			break
+		}
+		h.inflight[idx].Add(-1)
+	}
+	h.buf[idx].RecordValue(value)
+	h.inflight[idx].Add(-1)
+}
+
+// swap flips the active histogram and returns the one that was active up to this call, after
+// waiting for any record() calls that already captured that index to finish. It must only be
+// called from the single ticker goroutine that also merges and resets the returned histogram.
+func (h *swappableHistogram) swap() *hdrhistogram.Histogram {
+	prev := h.active.Load()
+	h.active.Store(1 - prev)
+	for h.inflight[prev].Load() > 0 {
+		runtime.Gosched()
+	}
+	return h.buf[prev]
+}
+
+// Op is a single primitive operation selected by a Workload to execute as one iteration of
+// the benchmark loop.
+type Op struct {
+	// Primitive is the name of the primitive the operation targets, used to label metrics.
+	Primitive string
+	// Name is the operation name (e.g. "put", "get"), used to label metrics.
+	Name string
+	// Exec performs the operation.
+	Exec func(ctx context.Context) error
+}
+
+// Workload selects the operations executed by each benchmark worker goroutine. Single-primitive
+// subcommands use the writerReaderWorkload adapter; the workload subcommand builds one from a
+// mixed-primitive spec file.
+type Workload interface {
+	// Keys returns the set of "primitive/op" labels the workload may produce, so the benchmark
+	// loop can pre-allocate per-worker histograms and avoid locking on the hot path.
+	Keys() []string
+	// Next selects the next operation to execute.
+	Next(ctx context.Context) Op
+}
+
+// writerReaderWorkload adapts the writer/reader closures used by the single-primitive
+// subcommands to the Workload interface so they share the same benchmark loop and per-op metrics.
+// The writer and reader closures log their own errors and return nothing, so every op produced
+// by this adapter completes with a nil error and is counted with result "success".
+type writerReaderWorkload struct {
+	primitive       string
+	writePercentage float32
+	writer          func(int)
+	reader          func(int)
+}
+
+func (w *writerReaderWorkload) Keys() []string {
+	return []string{w.primitive + "/write", w.primitive + "/read"}
+}
+
+func (w *writerReaderWorkload) Next(context.Context) Op {
+	n := rand.Intn(100)
+	if n < int(w.writePercentage*100) {
+		return Op{
+			Primitive: w.primitive,
+			Name:      "write",
+			Exec: func(context.Context) error {
+				w.writer(n)
+				return nil
+			},
+		}
+	}
+	return Op{
+		Primitive: w.primitive,
+		Name:      "read",
+		Exec: func(context.Context) error {
+			w.reader(n)
+			return nil
+		},
+	}
+}
+
+// workerState holds the per-worker latency histograms for a single benchmark goroutine, keyed
+// by "primitive/op" label so mixed workloads report per-primitive-per-op metrics.
+type workerState struct {
+	histograms map[string]*swappableHistogram
+}
+
+func newWorkerState(keys []string) *workerState {
+	histograms := make(map[string]*swappableHistogram, len(keys))
+	for _, key := range keys {
+		histograms[key] = newSwappableHistogram()
+	}
+	return &workerState{histograms: histograms}
+}
+
 var log = logging.GetLogger()
 
 func init() {
@@ -42,11 +177,21 @@ func getCommand() *cobra.Command {
 	cmd.AddCommand(getCounterCommand())
 	cmd.AddCommand(getMapCommand())
 	cmd.AddCommand(getSetCommand())
+	cmd.AddCommand(getLockCommand())
+	cmd.AddCommand(getElectionCommand())
+	cmd.AddCommand(getIndexedMapCommand())
+	cmd.AddCommand(getListCommand())
+	cmd.AddCommand(getValueCommand())
+	cmd.AddCommand(getWorkloadCommand())
 
 	cmd.PersistentFlags().StringP("name", "n", "test", "the name of the primitive to use")
 	cmd.PersistentFlags().IntP("concurrency", "c", 100, "the number of concurrent operations to run")
 	cmd.PersistentFlags().Float32P("write-percentage", "w", .5, "the percentage of operations to perform as writes")
 	cmd.PersistentFlags().DurationP("sample-interval", "i", 10*time.Second, "the interval at which to sample performance")
+	cmd.PersistentFlags().String("histogram-out", "", "a file to which the final merged latency histograms should be written in HdrHistogram log format")
+	cmd.PersistentFlags().String("metrics-addr", "", "an address (host:port) on which to serve Prometheus metrics; disabled if empty")
+	cmd.PersistentFlags().String("pushgateway-url", "", "a Prometheus pushgateway URL to push final metrics to before exiting; disabled if empty")
+	cmd.PersistentFlags().String("pushgateway-job", "atomix-bench", "the job name to use when pushing metrics to the pushgateway")
 	return cmd
 }
 
@@ -54,7 +199,7 @@ func getCounterCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use: "counter",
 		Run: func(cmd *cobra.Command, args []string) {
-			c, err := atomix.Counter("test").
+			c, err := atomix.Counter(primitiveName(cmd)).
 				Get(context.Background())
 			if err != nil {
 				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
@@ -93,7 +238,7 @@ func getMapCommand() *cobra.Command {
 				os.Exit(1)
 			}
 
-			m, err := atomix.Map[string, string]("test").
+			m, err := atomix.Map[string, string](primitiveName(cmd)).
 				Codec(types.Scalar[string]()).
 				Get(context.Background())
 			if err != nil {
@@ -151,7 +296,7 @@ func getSetCommand() *cobra.Command {
 				os.Exit(1)
 			}
 
-			m, err := atomix.Set[string]("test").
+			m, err := atomix.Set[string](primitiveName(cmd)).
 				Codec(types.Scalar[string]()).
 				Get(context.Background())
 			if err != nil {
@@ -195,7 +340,246 @@ func getSetCommand() *cobra.Command {
 	return cmd
 }
 
+func getLockCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: "lock",
+		Run: func(cmd *cobra.Command, args []string) {
+			l, err := atomix.Lock(primitiveName(cmd)).
+				Get(context.Background())
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			runBenchmark(cmd,
+				func(int) {
+					ctx := context.Background()
+					version, err := l.Lock(ctx)
+					if err != nil {
+						log.Warn(err)
+						return
+					}
+					if err := l.Unlock(ctx, version); err != nil {
+						log.Warn(err)
+					}
+				}, func(int) {
+					if _, err := l.Get(context.Background()); err != nil {
+						if !errors.IsNotFound(err) {
+							log.Warn(err)
+						}
+					}
+				})
+		},
+	}
+	return cmd
+}
+
+func getElectionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: "election",
+		Run: func(cmd *cobra.Command, args []string) {
+			e, err := atomix.Election(primitiveName(cmd)).
+				Get(context.Background())
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			candidate := uuid.New().String()
+			runBenchmark(cmd,
+				func(int) {
+					if _, err := e.Enter(context.Background(), candidate); err != nil {
+						log.Warn(err)
+					}
+				}, func(int) {
+					if _, err := e.Get(context.Background()); err != nil {
+						log.Warn(err)
+					}
+				})
+		},
+	}
+	return cmd
+}
+
+func getIndexedMapCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: "indexed-map",
+		Run: func(cmd *cobra.Command, args []string) {
+			numKeys, err := cmd.Flags().GetInt("num-keys")
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			m, err := atomix.IndexedMap[string, string](primitiveName(cmd)).
+				Codec(types.Scalar[string]()).
+				Get(context.Background())
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			keys := make([]string, numKeys)
+			for i := 0; i < numKeys; i++ {
+				keys[i] = uuid.New().String()
+			}
+
+			err = async.IterAsync(numKeys, func(i int) error {
+				_, err := m.Put(context.Background(), keys[i], uuid.New().String())
+				return err
+			})
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			runBenchmark(cmd,
+				func(n int) {
+					if n%2 == 0 {
+						if _, err := m.Put(context.Background(), keys[rand.Intn(numKeys)], uuid.New().String()); err != nil {
+							log.Warn(err)
+						}
+					} else {
+						if _, err := m.RemoveIndex(context.Background(), uint64(rand.Intn(numKeys))); err != nil {
+							if !errors.IsNotFound(err) {
+								log.Warn(err)
+							}
+						}
+					}
+				}, func(int) {
+					if _, err := m.GetIndex(context.Background(), uint64(rand.Intn(numKeys))); err != nil {
+						if !errors.IsNotFound(err) {
+							log.Warn(err)
+						}
+					}
+				})
+		},
+	}
+	cmd.Flags().IntP("num-keys", "k", 1000, "the number of unique map keys to use")
+	return cmd
+}
+
+func getListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: "list",
+		Run: func(cmd *cobra.Command, args []string) {
+			numElements, err := cmd.Flags().GetInt("num-elements")
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			l, err := atomix.List[string](primitiveName(cmd)).
+				Codec(types.Scalar[string]()).
+				Get(context.Background())
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			err = async.IterAsync(numElements, func(i int) error {
+				_, err := l.Append(context.Background(), uuid.New().String())
+				return err
+			})
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			runBenchmark(cmd,
+				func(n int) {
+					if n%2 == 0 {
+						if _, err := l.Append(context.Background(), uuid.New().String()); err != nil {
+							log.Warn(err)
+						}
+					} else {
+						if _, err := l.Remove(context.Background(), rand.Intn(numElements)); err != nil {
+							if !errors.IsNotFound(err) {
+								log.Warn(err)
+							}
+						}
+					}
+				}, func(int) {
+					if _, err := l.Get(context.Background(), rand.Intn(numElements)); err != nil {
+						if !errors.IsNotFound(err) {
+							log.Warn(err)
+						}
+					}
+				})
+		},
+	}
+	cmd.Flags().IntP("num-elements", "e", 1000, "the number of list elements to populate and index into")
+	return cmd
+}
+
+func getValueCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: "value",
+		Run: func(cmd *cobra.Command, args []string) {
+			v, err := atomix.Value[string](primitiveName(cmd)).
+				Codec(types.Scalar[string]()).
+				Get(context.Background())
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			runBenchmark(cmd,
+				func(int) {
+					if _, err := v.Set(context.Background(), uuid.New().String()); err != nil {
+						log.Warn(err)
+					}
+				}, func(int) {
+					if _, err := v.Get(context.Background()); err != nil {
+						if !errors.IsNotFound(err) {
+							log.Warn(err)
+						}
+					}
+				})
+		},
+	}
+	return cmd
+}
+
+// primitiveName reads the "-n"/"--name" persistent flag, exiting the process on error so every
+// single-primitive subcommand reports a bad flag parse the same way.
+func primitiveName(cmd *cobra.Command) string {
+	name, err := cmd.Flags().GetString("name")
+	if err != nil {
+		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+		os.Exit(1)
+	}
+	return name
+}
+
+// runBenchmark runs a benchmark for a single primitive using the legacy writer/reader split.
+// It's a thin adapter onto runWorkload for the Counter/Map/Set/Lock/Election/IndexedMap/List/
+// Value subcommands, which don't need a ramp-up schedule or a mix of primitives.
 func runBenchmark(cmd *cobra.Command, writer func(int), reader func(int)) {
+	writePercentage, err := cmd.Flags().GetFloat32("write-percentage")
+	if err != nil {
+		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+		os.Exit(1)
+	}
+	if writePercentage > 1 {
+		panic("writePercentage must be a decimal value between 0 and 1")
+	}
+	log.Infof("writePercentage: %f", writePercentage)
+
+	runWorkload(cmd, &writerReaderWorkload{
+		primitive:       cmd.Name(),
+		writePercentage: writePercentage,
+		writer:          writer,
+		reader:          reader,
+	}, nil, &writePercentage)
+}
+
+// runWorkload drives the benchmark goroutine loop for a Workload, reporting per-"primitive/op"
+// throughput and latency percentiles at each sample interval. If ramp is non-nil, the number of
+// worker goroutines is increased over time according to its schedule; otherwise the static
+// -c/--concurrency flag value is used for the whole run. writePercentage, if non-nil, is only
+// used to populate the atomix_bench_write_percentage gauge and has no effect on scheduling.
+func runWorkload(cmd *cobra.Command, w Workload, ramp *ConcurrencySchedule, writePercentage *float32) {
 	concurrency, err := cmd.Flags().GetInt("concurrency")
 	if err != nil {
 		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
@@ -206,54 +590,180 @@ func runBenchmark(cmd *cobra.Command, writer func(int), reader func(int)) {
 		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
 		os.Exit(1)
 	}
-	writePercentage, err := cmd.Flags().GetFloat32("write-percentage")
+	histogramOut, err := cmd.Flags().GetString("histogram-out")
 	if err != nil {
 		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
 		os.Exit(1)
 	}
-
-	if writePercentage > 1 {
-		panic("writePercentage must be a decimal value between 0 and 1")
+	metricsAddr, err := cmd.Flags().GetString("metrics-addr")
+	if err != nil {
+		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+		os.Exit(1)
+	}
+	pushgatewayURL, err := cmd.Flags().GetString("pushgateway-url")
+	if err != nil {
+		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+		os.Exit(1)
+	}
+	pushgatewayJob, err := cmd.Flags().GetString("pushgateway-job")
+	if err != nil {
+		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+		os.Exit(1)
 	}
 
 	log.Infof("Starting benchmark...")
-	log.Infof("concurrency: %d", concurrency)
 	log.Infof("sampleInterval: %s", sampleInterval)
-	log.Infof("writePercentage: %f", writePercentage)
 
-	opCount := &atomic.Uint64{}
-	totalDuration := &atomic.Int64{}
-	for i := 0; i < concurrency; i++ {
-		go func() {
-			for {
-				start := time.Now()
-				n := rand.Intn(100)
-				if n < int(writePercentage*100) {
-					writer(n)
-				} else {
-					reader(n)
-				}
-				totalDuration.Add(int64(time.Since(start)))
-				opCount.Add(1)
-			}
-		}()
+	var metrics *benchMetrics
+	if metricsAddr != "" || pushgatewayURL != "" {
+		metrics = newBenchMetrics()
+		if writePercentage != nil {
+			metrics.writePercentage.Set(float64(*writePercentage))
+		}
+		if metricsAddr != "" {
+			log.Infof("serving Prometheus metrics on %s/metrics", metricsAddr)
+			srv := startMetricsServer(metricsAddr, metrics.registry)
+			defer srv.Close()
+		}
+	}
+
+	keys := w.Keys()
+	finalHistograms := make(map[string]*hdrhistogram.Histogram, len(keys))
+	for _, key := range keys {
+		finalHistograms[key] = hdrhistogram.New(histogramMinValue, histogramMaxValue, histogramSigFigs)
+	}
+
+	// workers is only ever appended to from this goroutine (on ramp-up ticks below), so
+	// ranging over it on each sample tick is safe without locking.
+	var workers []*workerState
+	spawn := func(n int) {
+		for i := 0; i < n; i++ {
+			state := newWorkerState(keys)
+			workers = append(workers, state)
+			go runWorker(w, state, metrics)
+		}
+	}
+
+	var rampTickerCh <-chan time.Time
+	if ramp != nil {
+		log.Infof("concurrency: ramping %d -> %d by %d every %s", ramp.Start, ramp.Max, ramp.Step, time.Duration(ramp.Every))
+		spawn(ramp.Start)
+		rampTicker := time.NewTicker(time.Duration(ramp.Every))
+		defer rampTicker.Stop()
+		rampTickerCh = rampTicker.C
+	} else {
+		log.Infof("concurrency: %d", concurrency)
+		spawn(concurrency)
+	}
+	if metrics != nil {
+		metrics.concurrency.Set(float64(len(workers)))
 	}
 
 	// Wait for an interrupt signal
 	signalCh := make(chan os.Signal, 2)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 
-	ticker := time.NewTicker(10 * time.Second)
+	sampleTicker := time.NewTicker(sampleInterval)
+	defer sampleTicker.Stop()
+
 	for {
 		select {
-		case <-ticker.C:
-			count := opCount.Swap(0)
-			duration := totalDuration.Swap(0)
-			if count > 0 {
-				log.Infof("Completed %d operations in %s (~%s/request)", count, sampleInterval, time.Duration(duration/int64(count)))
+		case <-sampleTicker.C:
+			snapshots := make(map[string]*hdrhistogram.Histogram, len(keys))
+			for _, key := range keys {
+				snapshots[key] = hdrhistogram.New(histogramMinValue, histogramMaxValue, histogramSigFigs)
+			}
+			for _, state := range workers {
+				for key, hist := range state.histograms {
+					half := hist.swap()
+					snapshots[key].Merge(half)
+					half.Reset()
+				}
+			}
+			for _, key := range keys {
+				finalHistograms[key].Merge(snapshots[key])
+				logHistogram(key, snapshots[key], sampleInterval)
+			}
+		case <-rampTickerCh:
+			if len(workers) < ramp.Max {
+				n := ramp.Step
+				if len(workers)+n > ramp.Max {
+					n = ramp.Max - len(workers)
+				}
+				spawn(n)
+				log.Infof("concurrency: %d", len(workers))
+				if metrics != nil {
+					metrics.concurrency.Set(float64(len(workers)))
+				}
 			}
 		case <-signalCh:
+			if histogramOut != "" {
+				if err := writeHistogramLog(histogramOut, finalHistograms); err != nil {
+					log.Warn(err)
+				}
+			}
+			if metrics != nil && pushgatewayURL != "" {
+				if err := pushFinalMetrics(pushgatewayURL, pushgatewayJob, metrics.registry); err != nil {
+					log.Warn(err)
+				}
+			}
 			return
 		}
 	}
 }
+
+// runWorker repeatedly pulls the next Op from w, executes it, and records its latency under
+// the op's "primitive/op" key, looping until the process exits. If metrics is non-nil, every
+// op is also reported to the benchmark's Prometheus collectors.
+func runWorker(w Workload, state *workerState, metrics *benchMetrics) {
+	ctx := context.Background()
+	for {
+		op := w.Next(ctx)
+		start := time.Now()
+		err := op.Exec(ctx)
+		if err != nil {
+			log.Warn(err)
+		}
+		elapsed := time.Since(start)
+		if hist, ok := state.histograms[op.Primitive+"/"+op.Name]; ok {
+			hist.record(elapsed.Nanoseconds())
+		}
+		if metrics != nil {
+			metrics.record(op.Primitive, op.Name, elapsed, err)
+		}
+	}
+}
+
+// logHistogram logs throughput and latency percentiles for the operations recorded in h,
+// labelled by key (a "primitive/op" pair), during the last sampleInterval.
+func logHistogram(key string, h *hdrhistogram.Histogram, sampleInterval time.Duration) {
+	count := h.TotalCount()
+	if count == 0 {
+		return
+	}
+	log.Infof("Completed %d %s operations in %s (p50=%s, p90=%s, p99=%s, p99.9=%s, max=%s)",
+		count, key, sampleInterval,
+		time.Duration(h.ValueAtQuantile(50)),
+		time.Duration(h.ValueAtQuantile(90)),
+		time.Duration(h.ValueAtQuantile(99)),
+		time.Duration(h.ValueAtQuantile(99.9)),
+		time.Duration(h.Max()))
+}
+
+// writeHistogramLog writes the final merged per-"primitive/op" latency histograms to path in
+// HdrHistogram's standard log format so results can be post-processed and plotted.
+func writeHistogramLog(path string, histograms map[string]*hdrhistogram.Histogram) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := hdrhistogram.NewHistogramLogWriter(f)
+	for _, h := range histograms {
+		if err := w.OutputIntervalHistogram(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2022-present Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"net/http"
+	"time"
+)
+
+// benchMetrics holds the Prometheus collectors exported by a benchmark run, on their own
+// registry so /metrics and the pushgateway only ever see atomix-bench's own series.
+type benchMetrics struct {
+	registry        *prometheus.Registry
+	opsTotal        *prometheus.CounterVec
+	opDuration      *prometheus.HistogramVec
+	concurrency     prometheus.Gauge
+	writePercentage prometheus.Gauge
+}
+
+// newBenchMetrics creates and registers the benchmark's Prometheus collectors.
+func newBenchMetrics() *benchMetrics {
+	m := &benchMetrics{
+		registry: prometheus.NewRegistry(),
+		opsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "atomix_bench_ops_total",
+			Help: "Total number of primitive operations performed, by primitive, op, and result.",
+		}, []string{"primitive", "op", "result"}),
+		opDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "atomix_bench_op_duration_seconds",
+			Help:    "Latency of primitive operations in seconds, by primitive and op.",
+			Buckets: prometheus.ExponentialBucketsRange(100e-6, 30, 20),
+		}, []string{"primitive", "op"}),
+		concurrency: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "atomix_bench_concurrency",
+			Help: "The number of concurrent worker goroutines currently running.",
+		}),
+		writePercentage: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "atomix_bench_write_percentage",
+			Help: "The configured fraction of operations performed as writes.",
+		}),
+	}
+	m.registry.MustRegister(m.opsTotal, m.opDuration, m.concurrency, m.writePercentage)
+	return m
+}
+
+// record reports the outcome of a single operation.
+func (m *benchMetrics) record(primitive, op string, elapsed time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.opsTotal.WithLabelValues(primitive, op, result).Inc()
+	m.opDuration.WithLabelValues(primitive, op).Observe(elapsed.Seconds())
+}
+
+// startMetricsServer serves the registry's metrics in Prometheus text format at addr until
+// the returned server is closed.
+func startMetricsServer(addr string, registry *prometheus.Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Warn(err)
+		}
+	}()
+	return srv
+}
+
+// pushFinalMetrics pushes the registry's current metrics to a Prometheus pushgateway, for
+// short-lived benchmark runs (e.g. in CI) that need their final numbers recorded before exiting.
+func pushFinalMetrics(url, job string, registry *prometheus.Registry) error {
+	return push.New(url, job).Gatherer(registry).Push()
+}
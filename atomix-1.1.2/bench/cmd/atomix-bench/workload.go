@@ -0,0 +1,411 @@
+// SPDX-FileCopyrightText: 2022-present Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/atomix/atomix/api/errors"
+	"github.com/atomix/go-sdk/pkg/atomix"
+	"github.com/atomix/go-sdk/pkg/types"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"math/rand"
+	"os"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+// Duration wraps time.Duration so workload spec files can use human-readable strings
+// like "30s" instead of raw nanosecond counts.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler, parsing a duration string with time.ParseDuration.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// WorkloadSpec describes a mixed-primitive benchmark workload, loaded from a YAML or JSON file
+// via the `workload` subcommand.
+type WorkloadSpec struct {
+	// Concurrency, if set, ramps the number of worker goroutines up over the run instead of
+	// holding it fixed at the -c/--concurrency flag value.
+	Concurrency *ConcurrencySchedule `json:"concurrency,omitempty"`
+	// Primitives lists the primitives exercised by the workload and their op mixes.
+	Primitives []PrimitiveSpec `json:"primitives"`
+}
+
+// ConcurrencySchedule ramps the number of concurrent workers up over the course of a run,
+// starting at Start, adding Step workers every Every, up to Max.
+type ConcurrencySchedule struct {
+	Start int      `json:"start"`
+	Step  int      `json:"step"`
+	Every Duration `json:"every"`
+	Max   int      `json:"max"`
+}
+
+// validate checks that the ramp-up schedule is well-formed. It must be called before the
+// schedule reaches runWorkload, which builds a ticker from Every and would otherwise panic
+// on a zero or negative duration.
+func (s *ConcurrencySchedule) validate() error {
+	if s.Start <= 0 {
+		return fmt.Errorf("concurrency.start must be greater than 0")
+	}
+	if s.Step <= 0 {
+		return fmt.Errorf("concurrency.step must be greater than 0")
+	}
+	if s.Every <= 0 {
+		return fmt.Errorf("concurrency.every must be a positive duration")
+	}
+	if s.Max <= s.Start {
+		return fmt.Errorf("concurrency.max must be greater than concurrency.start")
+	}
+	return nil
+}
+
+// PrimitiveSpec describes one named primitive in a mixed workload: its type, its share of the
+// overall op rate, and the distribution of operations to perform against it.
+type PrimitiveSpec struct {
+	// Name is both the primitive's store name and the label used in reported metrics.
+	Name string `json:"name"`
+	// Type selects the primitive kind: counter, map, set, lock, list, or value.
+	Type string `json:"type"`
+	// Weight is this primitive's share of the overall op rate relative to the other primitives.
+	Weight float64 `json:"weight"`
+	// Keys is the size of the key space for keyed primitives (map, set, list). Defaults to 1000.
+	Keys int `json:"keys,omitempty"`
+	// ValueSize is the length, in bytes, of generated values. Defaults to a random UUID.
+	ValueSize int `json:"valueSize,omitempty"`
+	// Ops weights the operations performed against this primitive.
+	Ops []OpSpec `json:"ops"`
+}
+
+// OpSpec weights a single operation within a primitive's operation mix.
+type OpSpec struct {
+	Op     string  `json:"op"`
+	Weight float64 `json:"weight"`
+}
+
+// mixedWorkload combines several independently-weighted primitive workloads into a single
+// Workload, picking a primitive and then an op within it on every call to Next.
+type mixedWorkload struct {
+	primitives []*primitiveWorkload
+	weights    []float64
+	total      float64
+}
+
+func (w *mixedWorkload) Keys() []string {
+	var keys []string
+	for _, p := range w.primitives {
+		keys = append(keys, p.Keys()...)
+	}
+	return keys
+}
+
+func (w *mixedWorkload) Next(ctx context.Context) Op {
+	r := rand.Float64() * w.total
+	for i, p := range w.primitives {
+		r -= w.weights[i]
+		if r <= 0 {
+			return p.Next(ctx)
+		}
+	}
+	return w.primitives[len(w.primitives)-1].Next(ctx)
+}
+
+// primitiveWorkload executes a weighted mix of operations against a single named primitive.
+type primitiveWorkload struct {
+	name    string
+	ops     []string
+	weights []float64
+	total   float64
+	exec    []func(ctx context.Context) error
+}
+
+func (w *primitiveWorkload) Keys() []string {
+	keys := make([]string, len(w.ops))
+	for i, op := range w.ops {
+		keys[i] = w.name + "/" + op
+	}
+	return keys
+}
+
+func (w *primitiveWorkload) Next(ctx context.Context) Op {
+	r := rand.Float64() * w.total
+	idx := len(w.ops) - 1
+	for i := range w.ops {
+		r -= w.weights[i]
+		if r <= 0 {
+			idx = i
+			break
+		}
+	}
+	return Op{Primitive: w.name, Name: w.ops[idx], Exec: w.exec[idx]}
+}
+
+// randomValue generates a value for use in workload ops: a random alphanumeric string of the
+// requested size, or a UUID if no size was configured.
+func randomValue(size int) string {
+	if size <= 0 {
+		return uuid.New().String()
+	}
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = alphabet[rand.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+// buildWorkload constructs a Workload from a parsed WorkloadSpec, connecting to each configured
+// primitive and resolving its op mix.
+func buildWorkload(spec *WorkloadSpec) (Workload, error) {
+	if len(spec.Primitives) == 0 {
+		return nil, fmt.Errorf("workload spec must declare at least one primitive")
+	}
+	if spec.Concurrency != nil {
+		if err := spec.Concurrency.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &mixedWorkload{}
+	for _, p := range spec.Primitives {
+		pw, err := newPrimitiveWorkload(p)
+		if err != nil {
+			return nil, err
+		}
+		weight := p.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		w.primitives = append(w.primitives, pw)
+		w.weights = append(w.weights, weight)
+		w.total += weight
+	}
+	return w, nil
+}
+
+// newPrimitiveWorkload connects to the primitive described by spec and resolves its op mix
+// against the set of ops that primitive type supports.
+func newPrimitiveWorkload(spec PrimitiveSpec) (*primitiveWorkload, error) {
+	if len(spec.Ops) == 0 {
+		return nil, fmt.Errorf("primitive %q must declare at least one op", spec.Name)
+	}
+
+	numKeys := spec.Keys
+	if numKeys <= 0 {
+		numKeys = 1000
+	}
+
+	var handlers map[string]func(ctx context.Context) error
+	switch spec.Type {
+	case "counter":
+		c, err := atomix.Counter(spec.Name).Get(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		handlers = map[string]func(ctx context.Context) error{
+			"increment": func(ctx context.Context) error {
+				_, err := c.Increment(ctx, rand.Int63n(10))
+				return err
+			},
+			"decrement": func(ctx context.Context) error {
+				_, err := c.Decrement(ctx, rand.Int63n(10))
+				return err
+			},
+			"get": func(ctx context.Context) error {
+				_, err := c.Get(ctx)
+				return err
+			},
+		}
+	case "map":
+		m, err := atomix.Map[string, string](spec.Name).
+			Codec(types.Scalar[string]()).
+			Get(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, numKeys)
+		for i := range keys {
+			keys[i] = uuid.New().String()
+		}
+		handlers = map[string]func(ctx context.Context) error{
+			"put": func(ctx context.Context) error {
+				_, err := m.Put(ctx, keys[rand.Intn(numKeys)], randomValue(spec.ValueSize))
+				return err
+			},
+			"remove": func(ctx context.Context) error {
+				_, err := m.Remove(ctx, keys[rand.Intn(numKeys)])
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			},
+			"get": func(ctx context.Context) error {
+				_, err := m.Get(ctx, keys[rand.Intn(numKeys)])
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			},
+		}
+	case "set":
+		s, err := atomix.Set[string](spec.Name).
+			Codec(types.Scalar[string]()).
+			Get(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		elements := make([]string, numKeys)
+		for i := range elements {
+			elements[i] = uuid.New().String()
+		}
+		handlers = map[string]func(ctx context.Context) error{
+			"add": func(ctx context.Context) error {
+				_, err := s.Add(ctx, elements[rand.Intn(numKeys)])
+				return err
+			},
+			"remove": func(ctx context.Context) error {
+				_, err := s.Remove(ctx, elements[rand.Intn(numKeys)])
+				return err
+			},
+			"contains": func(ctx context.Context) error {
+				_, err := s.Contains(ctx, elements[rand.Intn(numKeys)])
+				return err
+			},
+		}
+	case "lock":
+		l, err := atomix.Lock(spec.Name).Get(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		handlers = map[string]func(ctx context.Context) error{
+			"lock": func(ctx context.Context) error {
+				version, err := l.Lock(ctx)
+				if err != nil {
+					return err
+				}
+				return l.Unlock(ctx, version)
+			},
+			"get": func(ctx context.Context) error {
+				_, err := l.Get(ctx)
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			},
+		}
+	case "list":
+		li, err := atomix.List[string](spec.Name).
+			Codec(types.Scalar[string]()).
+			Get(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		handlers = map[string]func(ctx context.Context) error{
+			"append": func(ctx context.Context) error {
+				_, err := li.Append(ctx, randomValue(spec.ValueSize))
+				return err
+			},
+			"remove": func(ctx context.Context) error {
+				_, err := li.Remove(ctx, rand.Intn(numKeys))
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			},
+			"get": func(ctx context.Context) error {
+				_, err := li.Get(ctx, rand.Intn(numKeys))
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			},
+		}
+	case "value":
+		v, err := atomix.Value[string](spec.Name).
+			Codec(types.Scalar[string]()).
+			Get(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		handlers = map[string]func(ctx context.Context) error{
+			"set": func(ctx context.Context) error {
+				_, err := v.Set(ctx, randomValue(spec.ValueSize))
+				return err
+			},
+			"get": func(ctx context.Context) error {
+				_, err := v.Get(ctx)
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			},
+		}
+	default:
+		return nil, fmt.Errorf("primitive %q has unsupported type %q", spec.Name, spec.Type)
+	}
+
+	pw := &primitiveWorkload{name: spec.Name}
+	for _, opSpec := range spec.Ops {
+		fn, ok := handlers[opSpec.Op]
+		if !ok {
+			return nil, fmt.Errorf("primitive %q of type %q does not support op %q", spec.Name, spec.Type, opSpec.Op)
+		}
+		pw.ops = append(pw.ops, opSpec.Op)
+		pw.weights = append(pw.weights, opSpec.Weight)
+		pw.exec = append(pw.exec, fn)
+		pw.total += opSpec.Weight
+	}
+	return pw, nil
+}
+
+func getWorkloadCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "workload",
+		Short: "Run a mixed-primitive workload described by a YAML or JSON spec file",
+		Run: func(cmd *cobra.Command, args []string) {
+			specPath, err := cmd.Flags().GetString("spec")
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			data, err := os.ReadFile(specPath)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			var spec WorkloadSpec
+			if err := yaml.Unmarshal(data, &spec); err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			w, err := buildWorkload(&spec)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			runWorkload(cmd, w, spec.Concurrency, nil)
+		},
+	}
+	cmd.Flags().String("spec", "", "the path to a workload spec file (YAML or JSON)")
+	_ = cmd.MarkFlagRequired("spec")
+	return cmd
+}